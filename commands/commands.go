@@ -110,6 +110,8 @@ func init() {
 	NanoboxCmd.AddCommand(CleanCmd)
 	NanoboxCmd.AddCommand(InfoCmd)
 	NanoboxCmd.AddCommand(TunnelCmd)
+	NanoboxCmd.AddCommand(ImageCmd)
+	NanoboxCmd.AddCommand(GenerateCmd)
 	NanoboxCmd.AddCommand(ImplodeCmd)
 	NanoboxCmd.AddCommand(DestroyCmd)
 	NanoboxCmd.AddCommand(StartCmd)
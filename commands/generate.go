@@ -0,0 +1,192 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nanobox-io/nanobox/models"
+)
+
+var (
+
+	// GenerateCmd ...
+	GenerateCmd = &cobra.Command{
+		Use:   "generate",
+		Short: "Generates host-level configuration from the current app.",
+		Long:  ``,
+	}
+
+	// GenerateSystemdCmd ...
+	GenerateSystemdCmd = &cobra.Command{
+		Use:   "systemd <app>",
+		Short: "Generates systemd unit files that boot the app's provider & components.",
+		Long: `
+Generates a .service unit per component (web, worker, data.*), plus a
+provider unit and a nanobox-<app>.target that wires them together with
+Requires=/After= so 'systemctl start nanobox-<app>.target' brings the
+whole app up, including 'nanobox run'/'nanobox tunnel' at boot.
+		`,
+
+		Run: func(ccmd *cobra.Command, args []string) {
+			if len(args) != 1 {
+				fmt.Println("I need an app to generate systemd units for")
+				return
+			}
+
+			units, err := generateSystemdUnits(args[0], generateSystemdFlags)
+			handleError(err)
+
+			if !generateSystemdFlags.files {
+				for _, unit := range units {
+					fmt.Printf("### %s\n%s\n", unit.name, unit.content)
+				}
+				return
+			}
+
+			dir := systemdUnitDir(generateSystemdFlags.user)
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				handleError(err)
+				return
+			}
+
+			for _, unit := range units {
+				path := filepath.Join(dir, unit.name)
+				if err := ioutil.WriteFile(path, []byte(unit.content), 0644); err != nil {
+					handleError(err)
+					return
+				}
+				fmt.Printf("wrote %s\n", path)
+			}
+		},
+	}
+
+	// generateSystemdFlags ...
+	generateSystemdFlags = generateFlags{}
+)
+
+// systemdUnit is a single generated unit file and the name it should be
+// written under
+type systemdUnit struct {
+	name    string
+	content string
+}
+
+// generateFlags ...
+type generateFlags struct {
+	user          bool
+	restartPolicy string
+	time          int
+	new           bool
+	files         bool
+}
+
+func init() {
+	GenerateSystemdCmd.Flags().BoolVar(&generateSystemdFlags.user, "user", false, "write user units to ~/.config/systemd/user/ instead of stdout")
+	GenerateSystemdCmd.Flags().StringVar(&generateSystemdFlags.restartPolicy, "restart-policy", "on-failure", "Restart= policy for the generated units (on-failure, always)")
+	GenerateSystemdCmd.Flags().IntVar(&generateSystemdFlags.time, "time", 90, "TimeoutStopSec for the generated units")
+	GenerateSystemdCmd.Flags().BoolVar(&generateSystemdFlags.new, "new", false, "regenerate ExecStartPre to 'nanobox run' a fresh container instead of resuming the existing one")
+	GenerateSystemdCmd.Flags().BoolVar(&generateSystemdFlags.files, "files", false, "write unit files to disk instead of printing them to stdout")
+
+	GenerateCmd.AddCommand(GenerateSystemdCmd)
+}
+
+// systemdUnitDir returns the directory generated unit files should be
+// written to
+func systemdUnitDir(user bool) string {
+	if user {
+		return filepath.Join(os.Getenv("HOME"), ".config/systemd/user")
+	}
+	return "/etc/systemd/system"
+}
+
+// generateSystemdUnits builds a provider unit, one unit per component, and a
+// target unit that wires them together for appName
+func generateSystemdUnits(appName string, flags generateFlags) ([]systemdUnit, error) {
+
+	app, err := models.LoadApp(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	providerUnit := fmt.Sprintf("nanobox-%s-provider.service", appName)
+
+	// --new asks for a fresh container on each boot: 'nanobox run' tears down
+	// and recreates it before the provider unit brings it up with 'nanobox
+	// start'. Without --new there's nothing to do before starting.
+	execStartPre := ""
+	if flags.new {
+		execStartPre = fmt.Sprintf("ExecStartPre=nanobox run %s\n", appName)
+	}
+
+	// bringing the provider up is a finite action, not a long-running
+	// process, so it's modeled as Type=oneshot; systemd refuses to load a
+	// oneshot unit with any Restart= other than "no", so none is set here
+	units := []systemdUnit{
+		{
+			name: providerUnit,
+			content: fmt.Sprintf(`[Unit]
+Description=nanobox provider for %[1]s
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+%[2]sExecStart=nanobox start %[1]s
+ExecStop=nanobox stop %[1]s
+TimeoutStopSec=%[3]d
+
+[Install]
+WantedBy=default.target
+`, appName, execStartPre, flags.time),
+		},
+	}
+
+	componentNames := []string{}
+	for _, component := range app.Components {
+		unitName := fmt.Sprintf("nanobox-%s-%s.service", appName, component.Name)
+		componentNames = append(componentNames, unitName)
+
+		// 'nanobox tunnel' runs in the foreground until stopped, so it's
+		// modeled as Type=simple (not oneshot, which would block
+		// 'systemctl start' waiting for it to exit)
+		units = append(units, systemdUnit{
+			name: unitName,
+			content: fmt.Sprintf(`[Unit]
+Description=nanobox component %[2]s for %[1]s
+Requires=%[3]s
+After=%[3]s
+
+[Service]
+Type=simple
+ExecStart=nanobox tunnel -a %[1]s %[2]s
+Restart=%[4]s
+TimeoutStopSec=%[5]d
+
+[Install]
+WantedBy=nanobox-%[1]s.target
+`, appName, component.Name, providerUnit, flags.restartPolicy, flags.time),
+		})
+	}
+
+	requires := providerUnit
+	for _, name := range componentNames {
+		requires += " " + name
+	}
+
+	units = append(units, systemdUnit{
+		name: fmt.Sprintf("nanobox-%s.target", appName),
+		content: fmt.Sprintf(`[Unit]
+Description=nanobox app %[1]s
+Requires=%[2]s
+After=%[2]s
+
+[Install]
+WantedBy=default.target
+`, appName, requires),
+	})
+
+	return units, nil
+}
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nanobox-io/nanobox/processor"
+	"github.com/nanobox-io/nanobox/util/print"
+)
+
+var (
+
+	// ImageCmd ...
+	ImageCmd = &cobra.Command{
+		Use:   "image",
+		Short: "Moves component images in and out of the provider without a registry.",
+		Long:  ``,
+	}
+
+	// ImageSaveCmd ...
+	ImageSaveCmd = &cobra.Command{
+		Use:   "save <component>[:tag]",
+		Short: "Saves a component image to a tarball for offline transfer.",
+		Long:  ``,
+
+		PreRun: validCheck("provider"),
+		Run: func(ccmd *cobra.Command, args []string) {
+			if len(args) != 1 {
+				fmt.Println("I need a component to save")
+				return
+			}
+			if imageCmdFlags.output == "" {
+				fmt.Println("I need an output path to save to (-o)")
+				return
+			}
+			processor.DefaultConfig.Meta["alias"] = app
+			processor.DefaultConfig.Meta["component"] = args[0]
+			processor.DefaultConfig.Meta["output"] = imageCmdFlags.output
+			print.OutputCommandErr(processor.Run("image_save", processor.DefaultConfig))
+		},
+	}
+
+	// ImageLoadCmd ...
+	ImageLoadCmd = &cobra.Command{
+		Use:   "load",
+		Short: "Loads a component image tarball into the provider.",
+		Long:  ``,
+
+		PreRun: validCheck("provider"),
+		Run: func(ccmd *cobra.Command, args []string) {
+			if imageCmdFlags.input == "" {
+				fmt.Println("I need an image tarball to load (-i)")
+				return
+			}
+			processor.DefaultConfig.Meta["alias"] = app
+			processor.DefaultConfig.Meta["input"] = imageCmdFlags.input
+			processor.DefaultConfig.Meta["component"] = imageCmdFlags.component
+			processor.DefaultConfig.Meta["image_source"] = "local"
+			print.OutputCommandErr(processor.Run("image_load", processor.DefaultConfig))
+		},
+	}
+
+	// imageCmdFlags ...
+	imageCmdFlags = struct {
+		output    string
+		input     string
+		component string
+	}{}
+)
+
+//
+func init() {
+	ImageCmd.PersistentFlags().StringVarP(&app, "app", "a", "", "app-name or alias")
+
+	ImageSaveCmd.Flags().StringVarP(&imageCmdFlags.output, "output", "o", "", "tarball to write the image to")
+
+	ImageLoadCmd.Flags().StringVarP(&imageCmdFlags.input, "input", "i", "", "tarball to load the image from")
+	ImageLoadCmd.Flags().StringVarP(&imageCmdFlags.component, "component", "", "", "component alias to register the loaded image under")
+
+	ImageCmd.AddCommand(ImageSaveCmd)
+	ImageCmd.AddCommand(ImageLoadCmd)
+}
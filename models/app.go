@@ -0,0 +1,52 @@
+// Copyright (c) 2015 Pagoda Box Inc
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License, v.
+// 2.0. If a copy of the MPL was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+//
+
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/nanobox-io/nanobox/util/config"
+)
+
+// Component is a single component of an app (web, worker, data.*); this is
+// the package's only App/Component/LoadApp, introduced for 'nanobox generate
+// systemd' to enumerate the components it needs a unit for.
+type Component struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+// App is a locally configured nanobox app and its component graph
+type App struct {
+	Name       string      `json:"name"`
+	Components []Component `json:"components"`
+}
+
+// appFile returns where name's metadata is persisted
+func appFile(name string) string {
+	return filepath.Join(config.GlobalDir(), "apps", name+".json")
+}
+
+// LoadApp reads name's metadata
+func LoadApp(name string) (*App, error) {
+
+	b, err := ioutil.ReadFile(appFile(name))
+	if err != nil {
+		return nil, fmt.Errorf("[models/app] LoadApp() failed to read app %q - %s", name, err.Error())
+	}
+
+	app := &App{}
+	if err := json.Unmarshal(b, app); err != nil {
+		return nil, fmt.Errorf("[models/app] LoadApp() failed to parse app %q - %s", name, err.Error())
+	}
+
+	return app, nil
+}
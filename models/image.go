@@ -0,0 +1,81 @@
+// Copyright (c) 2015 Pagoda Box Inc
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License, v.
+// 2.0. If a copy of the MPL was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+//
+
+package models
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/nanobox-io/nanobox/util/config"
+)
+
+// localImagesFile persists the component -> locally loaded image aliases
+// registered by 'nanobox image load'. This is its own side-table rather than
+// a field on App/Component, since it only matters to the image_load/save
+// processors and has no bearing on the rest of the app graph.
+var localImagesFile = filepath.Join(config.GlobalDir(), "local_images.json")
+
+// RegisterLocalImage records that component's image was loaded locally
+// (rather than pulled from a registry), so later run/deploy stages can skip
+// the pull step for it
+func RegisterLocalImage(component, image string) error {
+
+	images, err := loadLocalImages()
+	if err != nil {
+		return err
+	}
+
+	images[component] = image
+
+	b, err := json.Marshal(images)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(localImagesFile), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(localImagesFile, b, 0644)
+}
+
+// LocalImage returns the image previously registered for component via
+// RegisterLocalImage, and whether one was found
+func LocalImage(component string) (string, bool) {
+
+	images, err := loadLocalImages()
+	if err != nil {
+		return "", false
+	}
+
+	image, ok := images[component]
+	return image, ok
+}
+
+// loadLocalImages reads the local image alias registry, returning an empty
+// map if it doesn't exist yet
+func loadLocalImages() (map[string]string, error) {
+
+	images := map[string]string{}
+
+	b, err := ioutil.ReadFile(localImagesFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return images, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &images); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2015 Pagoda Box Inc
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License, v.
+// 2.0. If a copy of the MPL was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+//
+
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/nanobox-io/nanobox/util/config"
+)
+
+// providerFile is where the active provider's metadata is persisted between
+// commands
+var providerFile = filepath.Join(config.GlobalDir(), "provider.json")
+
+// Provider describes the running provider VM; this is the package's only
+// Provider/LoadProvider, referenced (but, until now, never defined in this
+// tree) by the share backends.
+type Provider struct {
+
+	// ID is the provider's unique identifier (container/vm name)
+	ID string `json:"id"`
+
+	// HostIP is the host machine's address as seen from inside the guest;
+	// it's what the guest mounts the share from
+	HostIP string `json:"host_ip"`
+
+	// MountIP is the guest's address as seen from the host; it's what gets
+	// granted access in the host-side NFS/SMB share config
+	MountIP string `json:"mount_ip"`
+
+	// DockerHost is the docker engine endpoint exposed by the provider, e.g.
+	// "tcp://127.0.0.1:2375"
+	DockerHost string `json:"docker_host"`
+
+	// MountType is the guest-side mount type to use for shares exported by the
+	// host, set by share.Add() to match whichever share.Backend is active
+	// ("nfs" or "cifs") so dev/run code knows which mount command to issue
+	MountType string `json:"mount_type"`
+}
+
+// LoadProvider reads the active provider's metadata
+func LoadProvider() (*Provider, error) {
+
+	b, err := ioutil.ReadFile(providerFile)
+	if err != nil {
+		return nil, fmt.Errorf("[models/provider] LoadProvider() failed to read %s - %s", providerFile, err.Error())
+	}
+
+	provider := &Provider{}
+	if err := json.Unmarshal(b, provider); err != nil {
+		return nil, fmt.Errorf("[models/provider] LoadProvider() failed to parse %s - %s", providerFile, err.Error())
+	}
+
+	return provider, nil
+}
+
+// Save persists the provider's metadata
+func (p *Provider) Save() error {
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(providerFile), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(providerFile, b, 0644)
+}
+
+// RunGuest runs args as a command inside the provider VM over ssh, returning
+// its combined output
+func (p *Provider) RunGuest(args ...string) ([]byte, error) {
+	sshArgs := append([]string{"-o", "StrictHostKeyChecking=no", fmt.Sprintf("docker@%s", p.MountIP)}, args...)
+	return exec.Command("ssh", sshArgs...).CombinedOutput()
+}
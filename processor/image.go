@@ -0,0 +1,112 @@
+// Copyright (c) 2015 Pagoda Box Inc
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License, v.
+// 2.0. If a copy of the MPL was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+//
+
+package processor
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/nanobox-io/nanobox/models"
+)
+
+func init() {
+	Register("image_save", func(control Control) Processor {
+		return &imageSave{control}
+	})
+	Register("image_load", func(control Control) Processor {
+		return &imageLoad{control}
+	})
+}
+
+// imageSave streams a component's image out of the provider's docker daemon
+// into a local tarball via 'docker save', without going through a registry
+type imageSave struct {
+	control Control
+}
+
+// Process ...
+func (p *imageSave) Process() error {
+	component := p.control.Meta["component"]
+	output := p.control.Meta["output"]
+
+	if component == "" {
+		return fmt.Errorf("[processor/image] image_save requires a component")
+	}
+	if output == "" {
+		return fmt.Errorf("[processor/image] image_save requires an output path")
+	}
+
+	provider, err := models.LoadProvider()
+	if err != nil {
+		return err
+	}
+
+	// the provider's docker daemon is the one holding the built image, so
+	// point the docker client at it rather than at the local daemon
+	cmd := exec.Command("docker", "-H", provider.DockerHost, "save", "-o", output, component)
+	if b, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("[processor/image] docker save %s: %s %s", component, b, err.Error())
+	}
+
+	return nil
+}
+
+// imageLoad pushes a tarball built by imageSave (or 'docker save' directly)
+// into the provider's docker daemon via 'docker load', registering it under
+// a local component alias so later run/deploy stages can skip the pull
+type imageLoad struct {
+	control Control
+}
+
+// Process ...
+func (p *imageLoad) Process() error {
+	input := p.control.Meta["input"]
+	if input == "" {
+		return fmt.Errorf("[processor/image] image_load requires an input path")
+	}
+
+	provider, err := models.LoadProvider()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("docker", "-H", provider.DockerHost, "load", "-i", input)
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("[processor/image] docker load %s: %s %s", input, b, err.Error())
+	}
+
+	component := p.control.Meta["component"]
+	if component == "" {
+		return nil
+	}
+
+	image, ok := parseLoadedImage(string(b))
+	if !ok {
+		return fmt.Errorf("[processor/image] image_load couldn't determine the loaded image name from: %s", b)
+	}
+
+	return models.RegisterLocalImage(component, image)
+}
+
+// parseLoadedImage pulls the image name/ID out of 'docker load's output,
+// which is a line like "Loaded image: nanobox/web:latest" or, for a bare
+// tarball with no tag, "Loaded image ID: sha256:<id>"
+func parseLoadedImage(output string) (string, bool) {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if after := strings.TrimPrefix(line, "Loaded image ID: "); after != line {
+			return after, true
+		}
+		if after := strings.TrimPrefix(line, "Loaded image: "); after != line {
+			return after, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2015 Pagoda Box Inc
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License, v.
+// 2.0. If a copy of the MPL was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+//
+
+// Package processor runs the named, multi-step operations behind nanobox's
+// commands (build, deploy, tunnel, image save/load, ...). Each operation is
+// registered under a name and built from the Control the command layer
+// populated, so commands stay thin cobra.Command wrappers and the actual
+// work lives here where it can be shared and tested independently of the
+// CLI.
+package processor
+
+import "fmt"
+
+// Control carries the arguments a command collected from flags/args down to
+// the Processor it runs; Meta is intentionally loose (map[string]string)
+// since each named processor only cares about the keys it documents. This is
+// the package's only Control/Run/DefaultConfig/DefaultControl -- console,
+// deploy, and tunnel only ever read/write Meta[...] on them, so that's all
+// this type needs to carry.
+type Control struct {
+	Meta map[string]string
+}
+
+// Processor is a single named, runnable operation
+type Processor interface {
+	Process() error
+}
+
+// DefaultConfig and DefaultControl are the shared Control instances commands
+// populate before calling Run; both exist because commands in this package
+// have historically used either name.
+var (
+	DefaultConfig  = Control{Meta: map[string]string{}}
+	DefaultControl = Control{Meta: map[string]string{}}
+)
+
+// registry maps a processor name to the func that builds it from a Control
+var registry = map[string]func(Control) Processor{}
+
+// Register makes a Processor available to Run under name
+func Register(name string, build func(Control) Processor) {
+	registry[name] = build
+}
+
+// Run builds and runs the Processor registered under name
+func Run(name string, control Control) error {
+	build, ok := registry[name]
+	if !ok {
+		return fmt.Errorf("[processor] Run() no processor registered for %q", name)
+	}
+
+	return build(control).Process()
+}
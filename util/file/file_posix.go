@@ -0,0 +1,30 @@
+// +build darwin linux
+
+// Copyright (c) 2015 Pagoda Box Inc
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License, v.
+// 2.0. If a copy of the MPL was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+//
+
+package file
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwner returns the uid/gid of fi, as reported by the platform's stat
+// syscall; ok is false if the platform doesn't expose ownership (windows)
+func fileOwner(fi os.FileInfo) (uid, gid int, ok bool) {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(stat.Uid), int(stat.Gid), true
+}
+
+// chown sets the ownership of path; a no-op on platforms without the concept
+func chown(path string, uid, gid int) error {
+	return os.Chown(path, uid, gid)
+}
@@ -0,0 +1,331 @@
+// Copyright (c) 2015 Pagoda Box Inc
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License, v.
+// 2.0. If a copy of the MPL was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+//
+
+package file
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildTar gzips and writes entries (in order) to a buffer, returning it as a
+// reader suitable for Untar
+func buildTar(t *testing.T, entries []*tar.Header, contents map[string]string) *bytes.Buffer {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+
+	for _, hdr := range entries {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("WriteHeader(%s): %s", hdr.Name, err)
+		}
+		if body, ok := contents[hdr.Name]; ok {
+			if _, err := tw.Write([]byte(body)); err != nil {
+				t.Fatalf("Write(%s): %s", hdr.Name, err)
+			}
+		}
+	}
+
+	tw.Close()
+	gzw.Close()
+
+	return buf
+}
+
+// TestUntarNeutralizesDotDotPath ensures a regular-file entry can't escape
+// dst via a "../" name -- it lands inside dst instead of outside it
+func TestUntarNeutralizesDotDotPath(t *testing.T) {
+	dst, err := ioutil.TempDir("", "nanobox-untar-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+
+	archive := buildTar(t, []*tar.Header{
+		{Name: "../../../escaped.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len("pwned"))},
+	}, map[string]string{"../../../escaped.txt": "pwned"})
+
+	if err := Untar(dst, nil, archive); err != nil {
+		t.Fatalf("Untar() failed: %s", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dst), "escaped.txt")); err == nil {
+		t.Fatal("escaped.txt was written outside dst")
+	}
+
+	if _, err := os.Stat(filepath.Join(dst, "escaped.txt")); err != nil {
+		t.Fatalf("expected escaped.txt to be extracted inside dst, got: %s", err)
+	}
+}
+
+// TestUntarRejectsEscapingSymlink ensures a symlink entry pointing outside
+// dst is rejected, and that a later entry can't use it to write outside dst
+func TestUntarRejectsEscapingSymlink(t *testing.T) {
+	dst, err := ioutil.TempDir("", "nanobox-untar-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+
+	outside, err := ioutil.TempDir("", "nanobox-untar-outside-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outside)
+
+	archive := buildTar(t, []*tar.Header{
+		{Name: "evil", Typeflag: tar.TypeSymlink, Linkname: outside, Mode: 0777},
+		{Name: "evil/passwd", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len("pwned"))},
+	}, map[string]string{"evil/passwd": "pwned"})
+
+	if err := Untar(dst, nil, archive); err == nil {
+		t.Fatal("expected Untar to reject a symlink escaping dst, got nil error")
+	}
+
+	if _, err := os.Stat(filepath.Join(outside, "passwd")); err == nil {
+		t.Fatal("passwd was written outside dst through the escaping symlink")
+	}
+}
+
+// TestUntarAllowsInBoundsSymlink is the control case: a symlink whose target
+// stays inside dst should extract normally
+func TestUntarAllowsInBoundsSymlink(t *testing.T) {
+	dst, err := ioutil.TempDir("", "nanobox-untar-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+
+	archive := buildTar(t, []*tar.Header{
+		{Name: "real", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "real/file.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len("hi"))},
+		{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "real", Mode: 0777},
+	}, map[string]string{"real/file.txt": "hi"})
+
+	if err := Untar(dst, nil, archive); err != nil {
+		t.Fatalf("Untar() of an in-bounds symlink failed: %s", err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dst, "link", "file.txt"))
+	if err != nil {
+		t.Fatalf("reading through extracted symlink: %s", err)
+	}
+	if string(b) != "hi" {
+		t.Fatalf("expected %q, got %q", "hi", string(b))
+	}
+}
+
+// TestUntarSkipsUnhandledTypeflags ensures an entry type Untar doesn't create
+// on disk (e.g. a fifo) is skipped instead of reaching applyMetadata with a
+// target that was never created
+func TestUntarSkipsUnhandledTypeflags(t *testing.T) {
+	dst, err := ioutil.TempDir("", "nanobox-untar-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+
+	archive := buildTar(t, []*tar.Header{
+		{Name: "fifo", Typeflag: tar.TypeFifo, Mode: 0644},
+		{Name: "file.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len("hi"))},
+	}, map[string]string{"file.txt": "hi"})
+
+	if err := Untar(dst, nil, archive); err != nil {
+		t.Fatalf("Untar() with an unhandled typeflag failed: %s", err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dst, "file.txt"))
+	if err != nil {
+		t.Fatalf("entry after the unhandled typeflag wasn't extracted: %s", err)
+	}
+	if string(b) != "hi" {
+		t.Fatalf("expected %q, got %q", "hi", string(b))
+	}
+}
+
+// sha256Hex is a small helper for building the ExpectedSHA256 a test server
+// is going to serve
+func sha256Hex(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+// TestFetchVerifiesChecksum covers both a matching and a mismatched
+// ExpectedSHA256 against a plain, non-ranged server
+func TestFetchVerifiesChecksum(t *testing.T) {
+	const body = "the quick brown fox jumps over the lazy dog"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	dst, err := ioutil.TempFile("", "nanobox-fetch-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(dst.Name())
+	defer dst.Close()
+
+	if err := Fetch(srv.URL, dst, FetchOptions{ExpectedSHA256: sha256Hex(body)}); err != nil {
+		t.Fatalf("Fetch() with a correct checksum failed: %s", err)
+	}
+
+	if err := Fetch(srv.URL, dst, FetchOptions{ExpectedSHA256: "not-the-right-digest"}); err == nil {
+		t.Fatal("expected Fetch() to fail on a checksum mismatch")
+	}
+}
+
+// TestFetchResumesFromExistingSize covers the happy-path resume: dst already
+// has the first half of the file, the server honors Range with a real 206,
+// and Fetch should only request (and write) the remainder
+func TestFetchResumesFromExistingSize(t *testing.T) {
+	const full = "the quick brown fox jumps over the lazy dog"
+	const split = 20
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+
+		if r.Method == "HEAD" {
+			return
+		}
+
+		rangeHeader := r.Header.Get("Range")
+		if rangeHeader == "" {
+			w.Write([]byte(full))
+			return
+		}
+
+		if rangeHeader != "bytes=20-" {
+			t.Errorf("unexpected Range header: %q", rangeHeader)
+		}
+		w.Header().Set("Content-Range", "bytes 20-43/44")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(full[split:]))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "nanobox-fetch-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "dst")
+	if err := ioutil.WriteFile(path, []byte(full[:split]), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	if err := Fetch(srv.URL, dst, FetchOptions{ExpectedSHA256: sha256Hex(full)}); err != nil {
+		t.Fatalf("Fetch() resume failed: %s", err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != full {
+		t.Fatalf("expected %q, got %q", full, string(b))
+	}
+}
+
+// TestFetchRestartsWhenRangeIgnored covers a server that advertises
+// Accept-Ranges but then ignores the Range header and returns a full 200 --
+// Fetch must detect this and rewrite the file from byte 0 instead of
+// corrupting it by writing the full body at the resume offset
+func TestFetchRestartsWhenRangeIgnored(t *testing.T) {
+	const full = "the quick brown fox jumps over the lazy dog"
+	const split = 20
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Write([]byte(full))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "nanobox-fetch-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "dst")
+	if err := ioutil.WriteFile(path, []byte("not the real prefix!"[:split]), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	if err := Fetch(srv.URL, dst, FetchOptions{ExpectedSHA256: sha256Hex(full)}); err != nil {
+		t.Fatalf("Fetch() failed: %s", err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != full {
+		t.Fatalf("expected the ignored-range response to overwrite the stale prefix with %q, got %q", full, string(b))
+	}
+}
+
+// TestFetchRetriesTransportError covers a server that fails the first
+// attempt and succeeds on the retry
+func TestFetchRetriesTransportError(t *testing.T) {
+	const body = "the quick brown fox jumps over the lazy dog"
+
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			return
+		}
+
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	dst, err := ioutil.TempFile("", "nanobox-fetch-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(dst.Name())
+	defer dst.Close()
+
+	if err := Fetch(srv.URL, dst, FetchOptions{ExpectedSHA256: sha256Hex(body), MaxRetries: 1}); err != nil {
+		t.Fatalf("Fetch() failed to recover after one retry: %s", err)
+	}
+
+	if attempts != 2 {
+		t.Fatalf("expected 2 attempts, got %d", attempts)
+	}
+}
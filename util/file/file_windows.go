@@ -0,0 +1,22 @@
+// +build windows
+
+// Copyright (c) 2015 Pagoda Box Inc
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License, v.
+// 2.0. If a copy of the MPL was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+//
+
+package file
+
+import "os"
+
+// fileOwner is always unsupported on windows, which has no uid/gid concept
+func fileOwner(fi os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
+
+// chown is a no-op on windows
+func chown(path string, uid, gid int) error {
+	return nil
+}
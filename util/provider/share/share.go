@@ -0,0 +1,136 @@
+// Copyright (c) 2015 Pagoda Box Inc
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License, v.
+// 2.0. If a copy of the MPL was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+//
+
+// Package share manages the host-side filesystem shares that are mounted into
+// the provider VM. Each operating system exposes a different share mechanism
+// (NFS exports on macOS/Linux, SMB on Windows), so the platform specifics are
+// implemented behind the Backend interface and selected at runtime.
+package share
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/nanobox-io/nanobox/models"
+)
+
+// shareBackendOverride, when set, forces a specific Backend regardless of
+// runtime.GOOS; it's surfaced as an env var so it can be exercised in tests
+// and CI where the host platform doesn't match the provider being targeted.
+const shareBackendOverride = "NANOBOX_SHARE_BACKEND"
+
+// Backend is implemented once per host platform and manages the lifecycle of
+// a single share entry identified by its host path.
+type Backend interface {
+
+	// Exists reports whether path is already shared
+	Exists(path string) bool
+
+	// Add shares path with the provider VM
+	Add(path string) error
+
+	// Remove un-shares path from the provider VM
+	Remove(path string) error
+
+	// Reload applies any pending share changes to the running share server
+	Reload() error
+
+	// GuestMountType is the guest-side mount type this backend's shares are
+	// consumed with ("nfs" or "cifs"), so dev/run code can pick the right
+	// mount command
+	GuestMountType() string
+
+	// MountGuest mounts hostPath at guestPath inside the provider VM
+	MountGuest(provider *models.Provider, hostPath, guestPath string) error
+}
+
+// backend is the active Backend for the current platform; it is set by init()
+// but can be forced via the NANOBOX_SHARE_BACKEND env var.
+var backend Backend
+
+func init() {
+	backend = newBackend()
+}
+
+// newBackend selects a Backend based on the NANOBOX_SHARE_BACKEND override
+// (when set), falling back to runtime.GOOS
+func newBackend() Backend {
+	switch os.Getenv(shareBackendOverride) {
+	case "darwin":
+		return darwinBackend{}
+	case "linux":
+		return linuxBackend{}
+	case "windows":
+		return windowsBackend{}
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		return windowsBackend{}
+	case "linux":
+		return linuxBackend{}
+	default:
+		return darwinBackend{}
+	}
+}
+
+// Exists reports whether path is already shared
+func Exists(path string) bool {
+	return backend.Exists(path)
+}
+
+// Add shares path with the provider VM: it registers the host-side share and
+// records the resulting guest mount type on the provider so dev/run code
+// knows which mount command to issue. Add only manages the host side of the
+// share; it deliberately doesn't mount path into the guest itself, since
+// dev/run mount (and unmount) it as part of bringing a component up and
+// down, and mounting it here too would double-mount it.
+func Add(path string) error {
+	if err := backend.Add(path); err != nil {
+		return err
+	}
+
+	provider, err := models.LoadProvider()
+	if err != nil {
+		return err
+	}
+
+	provider.MountType = backend.GuestMountType()
+	return provider.Save()
+}
+
+// Remove un-shares path from the provider VM
+func Remove(path string) error {
+	return backend.Remove(path)
+}
+
+// Reload applies any pending share changes to the running share server
+func Reload() error {
+	return backend.Reload()
+}
+
+// MountGuest mounts hostPath at guestPath inside the provider VM, using
+// whichever mount command matches provider.MountType. dev/run call this when
+// bringing up a component that needs hostPath shared, and are responsible
+// for unmounting it when the component stops.
+func MountGuest(provider *models.Provider, hostPath, guestPath string) error {
+	return backend.MountGuest(provider, hostPath, guestPath)
+}
+
+// mountGuestNFS mounts an NFS export from the host into the provider VM;
+// shared by the darwin and linux backends, which both export over NFS and
+// differ only in how the host side of the share is configured.
+func mountGuestNFS(provider *models.Provider, hostPath, guestPath string) error {
+	cmd := fmt.Sprintf("sudo mkdir -p %s && sudo mount -t nfs -o vers=3,actimeo=1 %s:%s %s",
+		guestPath, provider.HostIP, hostPath, guestPath)
+
+	if _, err := provider.RunGuest("sh", "-c", cmd); err != nil {
+		return fmt.Errorf("mount guest nfs: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package share
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+
+	"github.com/jcelliott/lumber"
+
+	"github.com/nanobox-io/nanobox/models"
+)
+
+// linuxBackend manages host shares via the kernel NFS server's /etc/exports,
+// reloaded with exportfs
+type linuxBackend struct{}
+
+func (linuxBackend) Exists(path string) bool {
+	// open file
+	b, err := ioutil.ReadFile(EXPORTSFILE)
+	if err != nil {
+		return false
+	}
+	// check to see if the path is in the file
+	return bytes.Contains(b, []byte(path+" "))
+}
+
+func (linuxBackend) Add(path string) error {
+
+	// get the provider because i need the mount ip
+	provider, err := models.LoadProvider()
+	if err != nil {
+		return err
+	}
+
+	// read exports file
+	existingFile, err := ioutil.ReadFile(EXPORTSFILE)
+	if err != nil {
+		// if the file didnt exist lets create an empty existingFile
+		existingFile = []byte("")
+	}
+
+	lineCheck := fmt.Sprintf("%s(rw,no_subtree_check,all_squash,anonuid=%v,anongid=%v)", provider.MountIP, uid(), gid())
+
+	lines := strings.Split(string(existingFile), "\n")
+
+	found := false
+	for i, line := range lines {
+		// get existing line
+		if strings.Contains(line, lineCheck) {
+			// add our path to the line
+			lines[i] = fmt.Sprintf("%s %s", path, line)
+			lines[i] = cleanLine(lines[i], lineCheck)
+			found = true
+			break
+		}
+	}
+	if !found {
+		lines = append(lines, fmt.Sprintf("%s %s", path, lineCheck))
+	}
+
+	// save
+	if err := ioutil.WriteFile(EXPORTSFILE, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		return err
+	}
+	return linuxBackend{}.Reload()
+}
+
+func (linuxBackend) Remove(path string) error {
+	// get the provider because i need the mount ip
+	provider, err := models.LoadProvider()
+	if err != nil {
+		return err
+	}
+
+	// read exports file
+	existingFile, err := ioutil.ReadFile(EXPORTSFILE)
+	if err != nil {
+		// if the error exists the file didnt exist.
+		lumber.Error("failed to read etc/exports: %s", err)
+		return nil
+	}
+
+	lineCheck := fmt.Sprintf("%s(rw,no_subtree_check,all_squash,anonuid=%v,anongid=%v)", provider.MountIP, uid(), gid())
+
+	existingLines := strings.Split(string(existingFile), "\n")
+	newLines := []string{}
+
+	for _, line := range existingLines {
+		// get existing line
+		if !strings.Contains(line, lineCheck) {
+			newLines = append(newLines, line)
+			continue
+		}
+
+		// add our path to the line
+		line = strings.Replace(line, fmt.Sprintf("%s ", path), "", 1)
+		if line != lineCheck {
+			// if there is still any paths left in our line
+			line = cleanLine(line, lineCheck)
+			newLines = append(newLines, line)
+		}
+	}
+
+	// save
+	if err := ioutil.WriteFile(EXPORTSFILE, []byte(strings.Join(newLines, "\n")), 0644); err != nil {
+		return err
+	}
+
+	return linuxBackend{}.Reload()
+}
+
+// Reload re-exports every entry in /etc/exports via exportfs
+func (linuxBackend) Reload() error {
+
+	// dont reload the server when testing
+	if flag.Lookup("test.v") != nil {
+		return nil
+	}
+
+	// re-read /etc/exports and sync the kernel's export table (-r), clearing
+	// any entries that no longer exist (-a)
+	cmd := exec.Command("exportfs", "-ra")
+	if b, err := cmd.CombinedOutput(); err != nil {
+		lumber.Debug("exportfs -ra: %s", b)
+		return fmt.Errorf("exportfs -ra: %s %s", b, err.Error())
+	}
+
+	return nil
+}
+
+// GuestMountType ...
+func (linuxBackend) GuestMountType() string {
+	return "nfs"
+}
+
+// MountGuest mounts the NFS export into the provider VM
+func (linuxBackend) MountGuest(provider *models.Provider, hostPath, guestPath string) error {
+	return mountGuestNFS(provider, hostPath, guestPath)
+}
@@ -17,7 +17,10 @@ import (
 // EXPORTSFILE ...
 var EXPORTSFILE = "/etc/exports"
 
-func Exists(path string) bool {
+// darwinBackend manages host shares via the macOS nfsd exports file
+type darwinBackend struct{}
+
+func (darwinBackend) Exists(path string) bool {
 	// open file
 	b, err := ioutil.ReadFile(EXPORTSFILE)
 	if err != nil {
@@ -27,7 +30,7 @@ func Exists(path string) bool {
 	return bytes.Contains(b, []byte(path+" "))
 }
 
-func Add(path string) error {
+func (darwinBackend) Add(path string) error {
 
 	// get the provider because i need the mount ip
 	provider, err := models.LoadProvider()
@@ -65,10 +68,10 @@ func Add(path string) error {
 	if err := ioutil.WriteFile(EXPORTSFILE, []byte(strings.Join(lines, "\n")), 0644); err != nil {
 		return err
 	}
-	return reloadServer()
+	return darwinBackend{}.Reload()
 }
 
-func Remove(path string) error {
+func (darwinBackend) Remove(path string) error {
 	// get the provider because i need the mount ip
 	provider, err := models.LoadProvider()
 	if err != nil {
@@ -109,11 +112,11 @@ func Remove(path string) error {
 		return err
 	}
 
-	return reloadServer()
+	return darwinBackend{}.Reload()
 }
 
-// reloadServer will reload the nfs server with the new export configuration
-func reloadServer() error {
+// Reload will reload the nfs server with the new export configuration
+func (darwinBackend) Reload() error {
 
 	// dont reload the server when testing
 	if flag.Lookup("test.v") != nil {
@@ -145,6 +148,16 @@ func reloadServer() error {
 	return nil
 }
 
+// GuestMountType ...
+func (darwinBackend) GuestMountType() string {
+	return "nfs"
+}
+
+// MountGuest mounts the NFS export into the provider VM
+func (darwinBackend) MountGuest(provider *models.Provider, hostPath, guestPath string) error {
+	return mountGuestNFS(provider, hostPath, guestPath)
+}
+
 func cleanLine(line, lineCheck string) string {
 	paths := strings.Split(strings.Replace(line, lineCheck, "", 1), " ")
 	goodPaths := []string{}
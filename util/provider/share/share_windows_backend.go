@@ -0,0 +1,89 @@
+package share
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/jcelliott/lumber"
+
+	"github.com/nanobox-io/nanobox/models"
+)
+
+// windowsBackend manages host shares as SMB/CIFS shares, mounted into the
+// provider VM over the network rather than through a VirtualBox/VMware
+// filesystem passthrough
+type windowsBackend struct{}
+
+func (windowsBackend) Exists(path string) bool {
+	cmd := exec.Command("powershell", "-NoProfile", "-Command",
+		fmt.Sprintf("Get-SmbShare -Name %q", shareName(path)))
+	return cmd.Run() == nil
+}
+
+func (w windowsBackend) Add(path string) error {
+
+	// New-SmbShare is idempotent-unfriendly (it errors if the share already
+	// exists), so remove any stale share with the same name first
+	if w.Exists(path) {
+		if err := w.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	name := shareName(path)
+
+	cmd := exec.Command("powershell", "-NoProfile", "-Command",
+		fmt.Sprintf("New-SmbShare -Name %q -Path %q -FullAccess Everyone", name, path))
+	if b, err := cmd.CombinedOutput(); err != nil {
+		lumber.Debug("New-SmbShare %s: %s", name, b)
+		return fmt.Errorf("New-SmbShare %s: %s %s", name, b, err.Error())
+	}
+
+	return w.Reload()
+}
+
+func (windowsBackend) Remove(path string) error {
+
+	name := shareName(path)
+
+	cmd := exec.Command("powershell", "-NoProfile", "-Command",
+		fmt.Sprintf("Remove-SmbShare -Name %q -Force", name))
+	if b, err := cmd.CombinedOutput(); err != nil {
+		lumber.Debug("Remove-SmbShare %s: %s", name, b)
+		return fmt.Errorf("Remove-SmbShare %s: %s %s", name, b, err.Error())
+	}
+
+	return nil
+}
+
+// Reload is a no-op on Windows; New-SmbShare/Remove-SmbShare take effect
+// immediately, unlike the export-file based NFS backends
+func (windowsBackend) Reload() error {
+	return nil
+}
+
+// GuestMountType ...
+func (windowsBackend) GuestMountType() string {
+	return "cifs"
+}
+
+// MountGuest mounts the SMB share into the provider VM over CIFS
+func (windowsBackend) MountGuest(provider *models.Provider, hostPath, guestPath string) error {
+	cmd := fmt.Sprintf("sudo mkdir -p %s && sudo mount -t cifs -o username=guest,vers=3.0 //%s/%s %s",
+		guestPath, provider.HostIP, shareName(hostPath), guestPath)
+
+	if _, err := provider.RunGuest("sh", "-c", cmd); err != nil {
+		return fmt.Errorf("mount guest cifs: %s", err.Error())
+	}
+	return nil
+}
+
+// shareName derives a stable SMB share name from a host path; share names
+// can't contain path separators, so we hash the path and prefix it so it
+// reads clearly in `net share`/Get-SmbShare output
+func shareName(path string) string {
+	sum := sha1.Sum([]byte(strings.ToLower(path)))
+	return fmt.Sprintf("nanobox-%x", sum[:8])
+}